@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net"
+	"time"
+
+	"github.com/TudorHulban/https-server/internal/clienthello"
+)
+
+// Connection wraps a net.Conn with the read/write primitives the server's
+// connection-handling loop relies on.
+type Connection struct {
+	conn net.Conn
+
+	clientHello *clienthello.Info
+}
+
+func NewConnection(conn net.Conn) *Connection {
+	return &Connection{
+		conn: conn,
+	}
+}
+
+func (c *Connection) Read() ([]byte, error) {
+	buf := make([]byte, 4096)
+
+	n, errRead := c.conn.Read(buf)
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	return buf[:n], nil
+}
+
+func (c *Connection) Write(data []byte) error {
+	_, errWrite := c.conn.Write(data)
+
+	return errWrite
+}
+
+func (c *Connection) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Connection) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// RawConn exposes the underlying net.Conn so callers can wrap it in an
+// io.Reader/io.Writer adapter (e.g. a *bufio.Reader for pipelined requests).
+func (c *Connection) RawConn() net.Conn {
+	return c.conn
+}
+
+// connWriter adapts Connection's error-only Write to the standard io.Writer
+// signature expected by things like httputil.NewChunkedWriter.
+type connWriter struct {
+	conn *Connection
+}
+
+func (w connWriter) Write(data []byte) (int, error) {
+	if errWrite := w.conn.Write(data); errWrite != nil {
+		return 0, errWrite
+	}
+
+	return len(data), nil
+}
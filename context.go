@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/TudorHulban/https-server/internal/clienthello"
+)
+
+// Context carries everything a Handler needs to inspect a request and write
+// a response: the parsed *http.Request, resolved path params, the server's
+// shutdown context, and a set of writer helpers built on top of the
+// server's bufferPool.
+type Context struct {
+	Request *http.Request
+	Params  map[string]string
+	Ctx     context.Context
+
+	server *Server
+	conn   *Connection
+
+	statusCode int
+	written    bool
+}
+
+func newContext(server *Server, conn *Connection, req *http.Request, params map[string]string) *Context {
+	return &Context{
+		Request:    req,
+		Params:     params,
+		Ctx:        server.baseCtx,
+		server:     server,
+		conn:       conn,
+		statusCode: http.StatusOK,
+	}
+}
+
+// Param returns the path parameter captured for name, or "" if absent.
+func (c *Context) Param(name string) string {
+	return c.Params[name]
+}
+
+// ClientHello returns the client's parsed ClientHello (version, cipher
+// suites, extensions, ALPN, SNI, ...), or nil if it could not be captured.
+func (c *Context) ClientHello() *clienthello.Info {
+	return c.conn.ClientHello()
+}
+
+// Status sets the status code to use for the next write and returns the
+// Context so calls can be chained, e.g. ctx.Status(404).String("not found").
+func (c *Context) Status(code int) *Context {
+	c.statusCode = code
+
+	return c
+}
+
+// Written reports whether a response has already been started on this
+// Context, e.g. so a caller knows not to write a second, conflicting
+// response after a Handler returns an error.
+func (c *Context) Written() bool {
+	return c.written
+}
+
+func (c *Context) String(body string) error {
+	c.written = true
+
+	return c.conn.Write(c.server.SendBody(c.statusCode, body))
+}
+
+func (c *Context) Bytes(body []byte) error {
+	c.written = true
+
+	buffer := c.server.bufferPool.Get().(*bytes.Buffer)
+	defer c.server.bufferPool.Put(buffer)
+	buffer.Reset()
+
+	buffer.WriteString(fmt.Sprintf("HTTP/1.1 %d %s\r\n", c.statusCode, http.StatusText(c.statusCode)))
+	buffer.WriteString("Content-Type: application/octet-stream\r\n")
+	buffer.WriteString(fmt.Sprintf("Content-Length: %d\r\n", len(body)))
+	buffer.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123)))
+	buffer.WriteString("\r\n")
+	buffer.Write(body)
+
+	return c.conn.Write(buffer.Bytes())
+}
+
+// Stream writes a chunked response, letting fn write a body of unknown
+// length as it becomes available instead of buffering it all up front.
+func (c *Context) Stream(fn func(w io.Writer) error) error {
+	c.written = true
+
+	buffer := c.server.bufferPool.Get().(*bytes.Buffer)
+	defer c.server.bufferPool.Put(buffer)
+	buffer.Reset()
+
+	buffer.WriteString(fmt.Sprintf("HTTP/1.1 %d %s\r\n", c.statusCode, http.StatusText(c.statusCode)))
+	buffer.WriteString("Transfer-Encoding: chunked\r\n")
+	buffer.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123)))
+	buffer.WriteString("\r\n")
+
+	if errWrite := c.conn.Write(buffer.Bytes()); errWrite != nil {
+		return errWrite
+	}
+
+	chunkWriter := httputil.NewChunkedWriter(connWriter{conn: c.conn})
+
+	if errBody := fn(chunkWriter); errBody != nil {
+		return errBody
+	}
+
+	if errClose := chunkWriter.Close(); errClose != nil {
+		return errClose
+	}
+
+	// NewChunkedWriter's Close only writes the terminating "0\r\n" chunk; per
+	// its own doc it does not send the final CRLF that ends the trailer
+	// section (we send no trailers), so that CRLF has to be written here.
+	return c.conn.Write([]byte("\r\n"))
+}
+
+func (c *Context) JSON(v interface{}) error {
+	data, errMarshal := json.Marshal(v)
+	if errMarshal != nil {
+		return fmt.Errorf("marshal json: %w", errMarshal)
+	}
+
+	c.written = true
+
+	buffer := c.server.bufferPool.Get().(*bytes.Buffer)
+	defer c.server.bufferPool.Put(buffer)
+	buffer.Reset()
+
+	buffer.WriteString(fmt.Sprintf("HTTP/1.1 %d %s\r\n", c.statusCode, http.StatusText(c.statusCode)))
+	buffer.WriteString("Content-Type: application/json\r\n")
+	buffer.WriteString(fmt.Sprintf("Content-Length: %d\r\n", len(data)))
+	buffer.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123)))
+	buffer.WriteString("\r\n")
+	buffer.Write(data)
+
+	return c.conn.Write(buffer.Bytes())
+}
@@ -0,0 +1,288 @@
+// Package clienthello parses a raw TLS ClientHello handshake record,
+// without depending on crypto/tls internals, so a server can inspect what a
+// client offered (version, ciphers, extensions, ALPN, SNI, ...) for
+// fingerprinting or bot-detection purposes.
+package clienthello
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	recordTypeHandshake      = 0x16
+	handshakeTypeClientHello = 0x01
+
+	extensionServerName          = 0x0000
+	extensionSupportedGroups     = 0x000a
+	extensionALPN                = 0x0010
+	extensionSignatureAlgorithms = 0x000d
+)
+
+// Info is the set of ClientHello fields useful for fingerprinting a client.
+type Info struct {
+	Version             uint16
+	CipherSuites        []uint16
+	Extensions          []uint16
+	ALPNProtocols       []string
+	ServerName          string
+	SupportedGroups     []uint16
+	SignatureAlgorithms []uint16
+}
+
+// Parse extracts Info from record, the raw bytes of a single TLS handshake
+// record carrying a ClientHello (as captured off the wire before the
+// handshake is handed to crypto/tls).
+func Parse(record []byte) (*Info, error) {
+	if len(record) < 5 {
+		return nil, fmt.Errorf("clienthello: record too short: %d bytes", len(record))
+	}
+
+	if record[0] != recordTypeHandshake {
+		return nil, fmt.Errorf("clienthello: not a handshake record (type %#x)", record[0])
+	}
+
+	recordLen := int(binary.BigEndian.Uint16(record[3:5]))
+	body := record[5:]
+
+	if len(body) < recordLen {
+		return nil, fmt.Errorf("clienthello: truncated record: want %d bytes, have %d", recordLen, len(body))
+	}
+
+	return parseHandshake(body[:recordLen])
+}
+
+func parseHandshake(body []byte) (*Info, error) {
+	if len(body) < 4 {
+		return nil, fmt.Errorf("clienthello: handshake header too short")
+	}
+
+	if body[0] != handshakeTypeClientHello {
+		return nil, fmt.Errorf("clienthello: not a ClientHello (type %#x)", body[0])
+	}
+
+	msgLen := int(body[1])<<16 | int(body[2])<<8 | int(body[3])
+	msg := body[4:]
+
+	if len(msg) < msgLen {
+		return nil, fmt.Errorf("clienthello: truncated ClientHello: want %d bytes, have %d", msgLen, len(msg))
+	}
+
+	msg = msg[:msgLen]
+
+	return parseClientHello(msg)
+}
+
+func parseClientHello(msg []byte) (*Info, error) {
+	info := &Info{}
+
+	if len(msg) < 2 {
+		return nil, fmt.Errorf("clienthello: missing client_version")
+	}
+
+	info.Version = binary.BigEndian.Uint16(msg[0:2])
+	offset := 2
+
+	// random (32 bytes)
+	if len(msg) < offset+32 {
+		return nil, fmt.Errorf("clienthello: truncated random")
+	}
+	offset += 32
+
+	// session_id
+	sessionIDLen, offset, errSession := readByteLen(msg, offset)
+	if errSession != nil {
+		return nil, fmt.Errorf("clienthello: session_id: %w", errSession)
+	}
+	offset += sessionIDLen
+
+	// cipher_suites
+	cipherSuitesLen, offset, errCiphers := readUint16Len(msg, offset)
+	if errCiphers != nil {
+		return nil, fmt.Errorf("clienthello: cipher_suites: %w", errCiphers)
+	}
+
+	if offset+cipherSuitesLen > len(msg) || cipherSuitesLen%2 != 0 {
+		return nil, fmt.Errorf("clienthello: invalid cipher_suites length %d", cipherSuitesLen)
+	}
+
+	for i := 0; i < cipherSuitesLen; i += 2 {
+		info.CipherSuites = append(info.CipherSuites, binary.BigEndian.Uint16(msg[offset+i:offset+i+2]))
+	}
+	offset += cipherSuitesLen
+
+	// compression_methods
+	compressionLen, offset, errCompression := readByteLen(msg, offset)
+	if errCompression != nil {
+		return nil, fmt.Errorf("clienthello: compression_methods: %w", errCompression)
+	}
+	offset += compressionLen
+
+	if offset == len(msg) {
+		// No extensions present.
+		return info, nil
+	}
+
+	extensionsLen, offset, errExtensions := readUint16Len(msg, offset)
+	if errExtensions != nil {
+		return nil, fmt.Errorf("clienthello: extensions: %w", errExtensions)
+	}
+
+	if offset+extensionsLen > len(msg) {
+		return nil, fmt.Errorf("clienthello: invalid extensions length %d", extensionsLen)
+	}
+
+	if errParse := parseExtensions(msg[offset:offset+extensionsLen], info); errParse != nil {
+		return nil, fmt.Errorf("clienthello: %w", errParse)
+	}
+
+	return info, nil
+}
+
+func parseExtensions(data []byte, info *Info) error {
+	offset := 0
+
+	for offset+4 <= len(data) {
+		extType := binary.BigEndian.Uint16(data[offset : offset+2])
+		extLen := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+		offset += 4
+
+		if offset+extLen > len(data) {
+			return fmt.Errorf("extension %#x: truncated body", extType)
+		}
+
+		extBody := data[offset : offset+extLen]
+		info.Extensions = append(info.Extensions, extType)
+
+		switch extType {
+		case extensionServerName:
+			if name, ok := parseServerName(extBody); ok {
+				info.ServerName = name
+			}
+
+		case extensionALPN:
+			info.ALPNProtocols = parseALPN(extBody)
+
+		case extensionSupportedGroups:
+			info.SupportedGroups = parseUint16List(extBody)
+
+		case extensionSignatureAlgorithms:
+			info.SignatureAlgorithms = parseUint16List(extBody)
+		}
+
+		offset += extLen
+	}
+
+	return nil
+}
+
+func parseServerName(data []byte) (string, bool) {
+	if len(data) < 2 {
+		return "", false
+	}
+
+	listLen := int(binary.BigEndian.Uint16(data[0:2]))
+	if 2+listLen > len(data) {
+		return "", false
+	}
+
+	entries := data[2 : 2+listLen]
+
+	offset := 0
+	for offset+3 <= len(entries) {
+		nameType := entries[offset]
+		nameLen := int(binary.BigEndian.Uint16(entries[offset+1 : offset+3]))
+		offset += 3
+
+		if offset+nameLen > len(entries) {
+			return "", false
+		}
+
+		if nameType == 0x00 {
+			return string(entries[offset : offset+nameLen]), true
+		}
+
+		offset += nameLen
+	}
+
+	return "", false
+}
+
+func parseALPN(data []byte) []string {
+	if len(data) < 2 {
+		return nil
+	}
+
+	listLen := int(binary.BigEndian.Uint16(data[0:2]))
+	if 2+listLen > len(data) {
+		return nil
+	}
+
+	var protocols []string
+
+	entries := data[2 : 2+listLen]
+	offset := 0
+
+	for offset < len(entries) {
+		protoLen := int(entries[offset])
+		offset++
+
+		if offset+protoLen > len(entries) {
+			break
+		}
+
+		protocols = append(protocols, string(entries[offset:offset+protoLen]))
+		offset += protoLen
+	}
+
+	return protocols
+}
+
+func parseUint16List(data []byte) []uint16 {
+	if len(data) < 2 {
+		return nil
+	}
+
+	listLen := int(binary.BigEndian.Uint16(data[0:2]))
+	if 2+listLen > len(data) || listLen%2 != 0 {
+		return nil
+	}
+
+	var values []uint16
+
+	for i := 0; i < listLen; i += 2 {
+		values = append(values, binary.BigEndian.Uint16(data[2+i:2+i+2]))
+	}
+
+	return values
+}
+
+func readByteLen(msg []byte, offset int) (int, int, error) {
+	if offset >= len(msg) {
+		return 0, offset, fmt.Errorf("missing length byte")
+	}
+
+	length := int(msg[offset])
+	offset++
+
+	if offset+length > len(msg) {
+		return 0, offset, fmt.Errorf("length %d exceeds message", length)
+	}
+
+	return length, offset, nil
+}
+
+func readUint16Len(msg []byte, offset int) (int, int, error) {
+	if offset+2 > len(msg) {
+		return 0, offset, fmt.Errorf("missing length field")
+	}
+
+	length := int(binary.BigEndian.Uint16(msg[offset : offset+2]))
+	offset += 2
+
+	if offset+length > len(msg) {
+		return 0, offset, fmt.Errorf("length %d exceeds message", length)
+	}
+
+	return length, offset, nil
+}
@@ -0,0 +1,141 @@
+package clienthello
+
+import (
+	"bytes"
+	"testing"
+)
+
+// buildClientHelloRecord assembles a minimal, well-formed TLS record
+// carrying a ClientHello handshake message, so Parse can be tested without
+// a real TLS stack.
+func buildClientHelloRecord(t *testing.T, serverName string, alpn []string) []byte {
+	t.Helper()
+
+	var body bytes.Buffer
+
+	body.Write([]byte{0x03, 0x03}) // client_version: TLS 1.2
+	body.Write(make([]byte, 32))   // random
+	body.WriteByte(0x00)           // session_id length: 0
+
+	cipherSuites := []byte{0xc0, 0x2b, 0x13, 0x01} // two cipher suites
+	body.Write([]byte{0x00, byte(len(cipherSuites))})
+	body.Write(cipherSuites)
+
+	body.Write([]byte{0x01, 0x00}) // compression_methods: 1 byte, "null"
+
+	var extensions bytes.Buffer
+
+	if serverName != "" {
+		var sniList bytes.Buffer
+		sniList.WriteByte(0x00) // name_type: host_name
+		sniList.Write([]byte{0x00, byte(len(serverName))})
+		sniList.WriteString(serverName)
+
+		var sniExt bytes.Buffer
+		sniExt.Write([]byte{0x00, byte(sniList.Len())})
+		sniExt.Write(sniList.Bytes())
+
+		extensions.Write([]byte{0x00, 0x00}) // extension type: server_name
+		extensions.Write([]byte{0x00, byte(sniExt.Len())})
+		extensions.Write(sniExt.Bytes())
+	}
+
+	if len(alpn) > 0 {
+		var protoList bytes.Buffer
+		for _, proto := range alpn {
+			protoList.WriteByte(byte(len(proto)))
+			protoList.WriteString(proto)
+		}
+
+		var alpnExt bytes.Buffer
+		alpnExt.Write([]byte{0x00, byte(protoList.Len())})
+		alpnExt.Write(protoList.Bytes())
+
+		extensions.Write([]byte{0x00, 0x10}) // extension type: ALPN
+		extensions.Write([]byte{0x00, byte(alpnExt.Len())})
+		extensions.Write(alpnExt.Bytes())
+	}
+
+	body.Write([]byte{0x00, byte(extensions.Len())})
+	body.Write(extensions.Bytes())
+
+	handshake := make([]byte, 4+body.Len())
+	handshake[0] = handshakeTypeClientHello
+	handshake[1] = byte(body.Len() >> 16)
+	handshake[2] = byte(body.Len() >> 8)
+	handshake[3] = byte(body.Len())
+	copy(handshake[4:], body.Bytes())
+
+	record := make([]byte, 5+len(handshake))
+	record[0] = recordTypeHandshake
+	record[1], record[2] = 0x03, 0x03 // record version: TLS 1.2
+	record[3] = byte(len(handshake) >> 8)
+	record[4] = byte(len(handshake))
+	copy(record[5:], handshake)
+
+	return record
+}
+
+func TestParseExtractsSNIAndALPN(t *testing.T) {
+	record := buildClientHelloRecord(t, "example.com", []string{"h2", "http/1.1"})
+
+	info, errParse := Parse(record)
+	if errParse != nil {
+		t.Fatalf("Parse: %v", errParse)
+	}
+
+	if info.ServerName != "example.com" {
+		t.Fatalf("ServerName = %q, want %q", info.ServerName, "example.com")
+	}
+
+	if len(info.ALPNProtocols) != 2 || info.ALPNProtocols[0] != "h2" || info.ALPNProtocols[1] != "http/1.1" {
+		t.Fatalf("ALPNProtocols = %v, want [h2 http/1.1]", info.ALPNProtocols)
+	}
+
+	if info.Version != 0x0303 {
+		t.Fatalf("Version = %#x, want %#x", info.Version, 0x0303)
+	}
+
+	if len(info.CipherSuites) != 2 || info.CipherSuites[0] != 0xc02b || info.CipherSuites[1] != 0x1301 {
+		t.Fatalf("CipherSuites = %v, want [0xc02b 0x1301]", info.CipherSuites)
+	}
+}
+
+func TestParseWithoutOptionalExtensions(t *testing.T) {
+	record := buildClientHelloRecord(t, "", nil)
+
+	info, errParse := Parse(record)
+	if errParse != nil {
+		t.Fatalf("Parse: %v", errParse)
+	}
+
+	if info.ServerName != "" {
+		t.Fatalf("ServerName = %q, want empty", info.ServerName)
+	}
+
+	if len(info.ALPNProtocols) != 0 {
+		t.Fatalf("ALPNProtocols = %v, want empty", info.ALPNProtocols)
+	}
+}
+
+func TestParseRejectsNonHandshakeRecord(t *testing.T) {
+	record := []byte{0x17, 0x03, 0x03, 0x00, 0x01, 0x00} // application_data
+
+	if _, errParse := Parse(record); errParse == nil {
+		t.Fatalf("expected an error for a non-handshake record")
+	}
+}
+
+func TestParseRejectsTruncatedRecord(t *testing.T) {
+	record := buildClientHelloRecord(t, "example.com", []string{"h2"})
+
+	if _, errParse := Parse(record[:len(record)-10]); errParse == nil {
+		t.Fatalf("expected an error for a truncated record")
+	}
+}
+
+func TestParseRejectsTooShortInput(t *testing.T) {
+	if _, errParse := Parse([]byte{0x16, 0x03}); errParse == nil {
+		t.Fatalf("expected an error for input shorter than a record header")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestKeepAliveDefaults(t *testing.T) {
+	tests := []struct {
+		name   string
+		proto  string
+		header string
+		want   bool
+	}{
+		{"HTTP/1.1 defaults to keep-alive", "HTTP/1.1", "", true},
+		{"HTTP/1.1 Connection: close", "HTTP/1.1", "close", false},
+		{"HTTP/1.0 defaults to close", "HTTP/1.0", "", false},
+		{"HTTP/1.0 Connection: keep-alive", "HTTP/1.0", "keep-alive", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newTestContext("GET", "/").Request
+			req.Proto = tt.proto
+
+			switch tt.proto {
+			case "HTTP/1.1":
+				req.ProtoMajor, req.ProtoMinor = 1, 1
+			case "HTTP/1.0":
+				req.ProtoMajor, req.ProtoMinor = 1, 0
+			}
+
+			if tt.header != "" {
+				req.Header.Set("Connection", tt.header)
+			}
+
+			if got := keepAlive(req); got != tt.want {
+				t.Fatalf("keepAlive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
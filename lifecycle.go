@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+const shutdownPollInterval = 10 * time.Millisecond
+
+// RegisterOnShutdown registers fn to run when Shutdown is called, before it
+// starts waiting for in-flight connections to drain. Mirrors net/http.Server.
+func (s *Server) RegisterOnShutdown(fn func()) {
+	s.shutdownMu.Lock()
+	defer s.shutdownMu.Unlock()
+
+	s.onShutdown = append(s.onShutdown, fn)
+}
+
+// Shutdown stops the listener, cancels the context embedded in every
+// in-flight Context so handlers can observe it, runs any RegisterOnShutdown
+// hooks, then waits for live connections to finish until ctx is done. Any
+// connections still open at that point are force-closed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.cancelBase()
+
+	s.mu.Lock()
+	listener := s.listener
+	s.mu.Unlock()
+
+	if listener != nil {
+		_ = listener.Close()
+	}
+
+	s.shutdownMu.Lock()
+	hooks := s.onShutdown
+	s.shutdownMu.Unlock()
+
+	for _, hook := range hooks {
+		hook()
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if !s.hasConnections() {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			s.closeAllConnections()
+
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops the listener and immediately force-closes every live
+// connection, without waiting for them to drain.
+func (s *Server) Close() error {
+	s.cancelBase()
+
+	s.mu.Lock()
+	listener := s.listener
+	s.mu.Unlock()
+
+	s.closeAllConnections()
+
+	if listener != nil {
+		return listener.Close()
+	}
+
+	return nil
+}
+
+func (s *Server) trackConnection(conn *Connection) {
+	s.connections.Store(conn, struct{}{})
+}
+
+func (s *Server) untrackConnection(conn *Connection) {
+	s.connections.Delete(conn)
+}
+
+func (s *Server) hasConnections() bool {
+	hasAny := false
+
+	s.connections.Range(func(_, _ interface{}) bool {
+		hasAny = true
+
+		return false
+	})
+
+	return hasAny
+}
+
+func (s *Server) closeAllConnections() {
+	s.connections.Range(func(key, _ interface{}) bool {
+		if conn, ok := key.(*Connection); ok {
+			_ = conn.Close()
+		}
+
+		return true
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func newTestServer() *Server {
+	server := &Server{}
+	server.baseCtx, server.cancelBase = context.WithCancel(context.Background())
+
+	return server
+}
+
+func newPipeConnection(t *testing.T) (*Connection, net.Conn) {
+	t.Helper()
+
+	serverSide, clientSide := net.Pipe()
+	t.Cleanup(func() { clientSide.Close() })
+
+	return NewConnection(serverSide), clientSide
+}
+
+func TestShutdownWaitsForConnectionsToDrain(t *testing.T) {
+	server := newTestServer()
+
+	conn, _ := newPipeConnection(t)
+	server.trackConnection(conn)
+
+	var hookRan bool
+	server.RegisterOnShutdown(func() { hookRan = true })
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Shutdown(context.Background())
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("Shutdown returned early (err=%v) before the connection was untracked", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if !hookRan {
+		t.Fatalf("expected the RegisterOnShutdown hook to have run")
+	}
+
+	server.untrackConnection(conn)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Shutdown returned error %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Shutdown did not return after the connection drained")
+	}
+}
+
+func TestShutdownForceClosesPastDeadline(t *testing.T) {
+	server := newTestServer()
+
+	conn, clientSide := newPipeConnection(t)
+	server.trackConnection(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if errShutdown := server.Shutdown(ctx); errShutdown == nil {
+		t.Fatalf("expected Shutdown to report the context deadline error")
+	}
+
+	buf := make([]byte, 1)
+	if _, err := clientSide.Read(buf); err == nil {
+		t.Fatalf("expected the connection to be closed once Shutdown's deadline passed")
+	}
+}
+
+func TestCloseForceClosesImmediately(t *testing.T) {
+	server := newTestServer()
+
+	conn, clientSide := newPipeConnection(t)
+	server.trackConnection(conn)
+
+	if errClose := server.Close(); errClose != nil {
+		t.Fatalf("Close returned error: %v", errClose)
+	}
+
+	buf := make([]byte, 1)
+	if _, err := clientSide.Read(buf); err == nil {
+		t.Fatalf("expected the connection to be closed by Close")
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tokenBucket is a simple token-bucket rate limiter: capacity tokens
+// refilled continuously at refillRate tokens/sec, one token spent per
+// Allow call that succeeds.
+type tokenBucket struct {
+	mu sync.Mutex
+
+	capacity   float64
+	tokens     float64
+	refillRate float64
+	last       time.Time
+}
+
+func newTokenBucket(ratePerSecond float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		capacity:   float64(burst),
+		tokens:     float64(burst),
+		refillRate: ratePerSecond,
+		last:       time.Now(),
+	}
+}
+
+func (b *tokenBucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.refillRate
+	b.last = now
+
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
+
+// ipBucketCache hands out a per-IP tokenBucket, evicting the least recently
+// used entry once capacity is exceeded so memory stays bounded under an
+// attack spraying distinct source IPs.
+type ipBucketCache struct {
+	mu sync.Mutex
+
+	capacity      int
+	ratePerSecond float64
+	burst         int
+
+	order *list.List
+	items map[string]*list.Element
+}
+
+type ipBucketEntry struct {
+	ip     string
+	bucket *tokenBucket
+}
+
+func newIPBucketCache(capacity int, ratePerSecond float64, burst int) *ipBucketCache {
+	return &ipBucketCache{
+		capacity:      capacity,
+		ratePerSecond: ratePerSecond,
+		burst:         burst,
+		order:         list.New(),
+		items:         make(map[string]*list.Element),
+	}
+}
+
+func (c *ipBucketCache) bucketFor(ip string) *tokenBucket {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[ip]; ok {
+		c.order.MoveToFront(el)
+
+		return el.Value.(*ipBucketEntry).bucket
+	}
+
+	entry := &ipBucketEntry{
+		ip:     ip,
+		bucket: newTokenBucket(c.ratePerSecond, c.burst),
+	}
+
+	c.items[ip] = c.order.PushFront(entry)
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.items, oldest.Value.(*ipBucketEntry).ip)
+		}
+	}
+
+	return entry.bucket
+}
+
+// Metrics is a snapshot of a Limiter's counters, suitable for wiring to
+// Prometheus or any other metrics backend.
+type Metrics struct {
+	Accepted          int64
+	Rejected          int64
+	InFlightRequests  int64
+	ActiveConnections int64
+}
+
+const defaultMaxTrackedIPs = 10_000
+
+// Limiter enforces a max concurrent-connection cap, a max in-flight request
+// cap, and global/per-IP request-rate token buckets.
+type Limiter struct {
+	maxConnections int64
+	maxInFlight    int64
+
+	global *tokenBucket
+	perIP  *ipBucketCache
+
+	activeConnections int64
+	inFlightRequests  int64
+	accepted          int64
+	rejected          int64
+}
+
+func newLimiter() *Limiter {
+	return &Limiter{
+		perIP: newIPBucketCache(defaultMaxTrackedIPs, 0, 0),
+	}
+}
+
+// allowConnection reports whether a newly accepted connection may proceed,
+// incrementing the active-connection count if so. Every accepted
+// connection must eventually call releaseConnection.
+func (l *Limiter) allowConnection() bool {
+	if l.maxConnections > 0 && atomic.LoadInt64(&l.activeConnections) >= l.maxConnections {
+		atomic.AddInt64(&l.rejected, 1)
+
+		return false
+	}
+
+	atomic.AddInt64(&l.activeConnections, 1)
+	atomic.AddInt64(&l.accepted, 1)
+
+	return true
+}
+
+func (l *Limiter) releaseConnection() {
+	atomic.AddInt64(&l.activeConnections, -1)
+}
+
+// allowRequest reports whether a request from ip may proceed: the in-flight
+// cap and the global bucket apply to every request, the per-IP bucket only
+// when an IP has been configured. Every allowed request must eventually
+// call releaseRequest.
+func (l *Limiter) allowRequest(ip string) bool {
+	if l.maxInFlight > 0 && atomic.LoadInt64(&l.inFlightRequests) >= l.maxInFlight {
+		atomic.AddInt64(&l.rejected, 1)
+
+		return false
+	}
+
+	if l.global != nil && !l.global.Allow() {
+		atomic.AddInt64(&l.rejected, 1)
+
+		return false
+	}
+
+	if l.perIP != nil && l.perIP.ratePerSecond > 0 && ip != "" {
+		if !l.perIP.bucketFor(ip).Allow() {
+			atomic.AddInt64(&l.rejected, 1)
+
+			return false
+		}
+	}
+
+	atomic.AddInt64(&l.inFlightRequests, 1)
+
+	return true
+}
+
+func (l *Limiter) releaseRequest() {
+	atomic.AddInt64(&l.inFlightRequests, -1)
+}
+
+func (l *Limiter) metrics() Metrics {
+	return Metrics{
+		Accepted:          atomic.LoadInt64(&l.accepted),
+		Rejected:          atomic.LoadInt64(&l.rejected),
+		InFlightRequests:  atomic.LoadInt64(&l.inFlightRequests),
+		ActiveConnections: atomic.LoadInt64(&l.activeConnections),
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucketAllowsUpToBurstThenBlocks(t *testing.T) {
+	bucket := newTokenBucket(1, 3)
+
+	for i := 0; i < 3; i++ {
+		if !bucket.Allow() {
+			t.Fatalf("Allow() #%d = false, want true within burst", i)
+		}
+	}
+
+	if bucket.Allow() {
+		t.Fatalf("Allow() after exhausting the burst = true, want false")
+	}
+}
+
+func TestTokenBucketRefillsOverTime(t *testing.T) {
+	bucket := newTokenBucket(100, 1)
+
+	if !bucket.Allow() {
+		t.Fatalf("expected the first Allow() to succeed")
+	}
+
+	if bucket.Allow() {
+		t.Fatalf("expected the bucket to be empty right after spending its only token")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if !bucket.Allow() {
+		t.Fatalf("expected a refill at 100 tokens/sec after 20ms")
+	}
+}
+
+func TestIPBucketCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newIPBucketCache(2, 10, 10)
+
+	first := cache.bucketFor("1.1.1.1")
+	cache.bucketFor("2.2.2.2")
+	cache.bucketFor("3.3.3.3") // evicts 1.1.1.1, the least recently used
+
+	if len(cache.items) != 2 {
+		t.Fatalf("cache size = %d, want 2", len(cache.items))
+	}
+
+	if _, ok := cache.items["1.1.1.1"]; ok {
+		t.Fatalf("expected 1.1.1.1 to have been evicted")
+	}
+
+	again := cache.bucketFor("1.1.1.1")
+	if again == first {
+		t.Fatalf("expected a fresh bucket after eviction, got the original one back")
+	}
+}
+
+func TestIPBucketCacheTouchProtectsFromEviction(t *testing.T) {
+	cache := newIPBucketCache(2, 10, 10)
+
+	cache.bucketFor("1.1.1.1")
+	cache.bucketFor("2.2.2.2")
+	cache.bucketFor("1.1.1.1") // touch: now 2.2.2.2 is least recently used
+	cache.bucketFor("3.3.3.3") // should evict 2.2.2.2, not 1.1.1.1
+
+	if _, ok := cache.items["1.1.1.1"]; !ok {
+		t.Fatalf("expected 1.1.1.1 to survive eviction after being touched")
+	}
+
+	if _, ok := cache.items["2.2.2.2"]; ok {
+		t.Fatalf("expected 2.2.2.2 to have been evicted")
+	}
+}
+
+func TestLimiterMaxConnections(t *testing.T) {
+	limiter := newLimiter()
+	limiter.maxConnections = 1
+
+	if !limiter.allowConnection() {
+		t.Fatalf("expected the first connection to be allowed")
+	}
+
+	if limiter.allowConnection() {
+		t.Fatalf("expected a second connection past the cap to be rejected")
+	}
+
+	limiter.releaseConnection()
+
+	if !limiter.allowConnection() {
+		t.Fatalf("expected a connection to be allowed again after release")
+	}
+}
+
+func TestLimiterMaxInFlightRequests(t *testing.T) {
+	limiter := newLimiter()
+	limiter.maxInFlight = 1
+
+	if !limiter.allowRequest("10.0.0.1") {
+		t.Fatalf("expected the first request to be allowed")
+	}
+
+	if limiter.allowRequest("10.0.0.2") {
+		t.Fatalf("expected a second in-flight request past the cap to be rejected")
+	}
+
+	limiter.releaseRequest()
+
+	if !limiter.allowRequest("10.0.0.2") {
+		t.Fatalf("expected a request to be allowed again after release")
+	}
+}
+
+func TestLimiterMetrics(t *testing.T) {
+	limiter := newLimiter()
+	limiter.maxConnections = 1
+
+	limiter.allowConnection()
+	limiter.allowConnection() // rejected
+
+	metrics := limiter.metrics()
+
+	if metrics.Accepted != 1 {
+		t.Fatalf("Accepted = %d, want 1", metrics.Accepted)
+	}
+
+	if metrics.Rejected != 1 {
+		t.Fatalf("Rejected = %d, want 1", metrics.Rejected)
+	}
+
+	if metrics.ActiveConnections != 1 {
+		t.Fatalf("ActiveConnections = %d, want 1", metrics.ActiveConnections)
+	}
+}
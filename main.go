@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	address := flag.String("address", ":8443", "address to listen on")
+	certFile := flag.String("cert", "server.crt", "path to the TLS certificate")
+	keyFile := flag.String("key", "server.key", "path to the TLS private key")
+	flag.Parse()
+
+	router := NewRouter()
+	router.GET("/healthz", func(ctx *Context) error {
+		return ctx.Status(http.StatusOK).String("ok")
+	})
+
+	server, errNewServer := NewServer(*certFile, *keyFile, router)
+	if errNewServer != nil {
+		log.Fatalf("create server: %v", errNewServer)
+	}
+
+	go func() {
+		if errRun := server.Run(*address); errRun != nil {
+			log.Fatalf("server run: %v", errRun)
+		}
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	<-sigCh
+
+	log.Print("shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if errShutdown := server.Shutdown(ctx); errShutdown != nil {
+		log.Fatalf("graceful shutdown: %v", errShutdown)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+// newPipelineTestServer builds a Server with a real router and bufferPool,
+// wired to handle connections but with no TLS/listener, so handleConnection
+// can be driven directly over a net.Pipe().
+func newPipelineTestServer(router *Router) *Server {
+	server := &Server{
+		bufferPool: sync.Pool{
+			New: func() interface{} {
+				return bytes.NewBuffer(nil)
+			},
+		},
+		router: router,
+	}
+	server.baseCtx, server.cancelBase = context.WithCancel(context.Background())
+
+	return server
+}
+
+// TestHandleConnectionPipelinedChunkedResponse drives handleConnection over
+// a net.Pipe() with two pipelined requests on one connection: a plain
+// String response followed by a chunked Stream response. It verifies both
+// responses are framed correctly enough for net/http's own client-side
+// response reader to parse them back to back off the same byte stream.
+func TestHandleConnectionPipelinedChunkedResponse(t *testing.T) {
+	router := NewRouter()
+	router.GET("/hello", func(ctx *Context) error {
+		return ctx.String("hello")
+	})
+	router.GET("/stream", func(ctx *Context) error {
+		return ctx.Stream(func(w io.Writer) error {
+			if _, errWrite := w.Write([]byte("chunk1")); errWrite != nil {
+				return errWrite
+			}
+
+			_, errWrite := w.Write([]byte("chunk2"))
+
+			return errWrite
+		})
+	})
+
+	server := newPipelineTestServer(router)
+
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+
+	conn := NewConnection(serverSide)
+
+	done := make(chan struct{})
+	go func() {
+		server.handleConnection(conn)
+		close(done)
+	}()
+
+	requests := "GET /hello HTTP/1.1\r\nHost: example.com\r\n\r\n" +
+		"GET /stream HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n"
+
+	go func() {
+		_, _ = clientSide.Write([]byte(requests))
+	}()
+
+	reader := bufio.NewReader(clientSide)
+
+	resp1, errRead1 := http.ReadResponse(reader, nil)
+	if errRead1 != nil {
+		t.Fatalf("reading first pipelined response: %v", errRead1)
+	}
+
+	body1, errBody1 := io.ReadAll(resp1.Body)
+	resp1.Body.Close()
+	if errBody1 != nil {
+		t.Fatalf("reading first response body: %v", errBody1)
+	}
+
+	if string(body1) != "hello" {
+		t.Fatalf("first response body = %q, want %q", body1, "hello")
+	}
+
+	resp2, errRead2 := http.ReadResponse(reader, nil)
+	if errRead2 != nil {
+		t.Fatalf("reading second pipelined (chunked) response: %v", errRead2)
+	}
+
+	body2, errBody2 := io.ReadAll(resp2.Body)
+	resp2.Body.Close()
+	if errBody2 != nil {
+		t.Fatalf("reading second response body: %v", errBody2)
+	}
+
+	if string(body2) != "chunk1chunk2" {
+		t.Fatalf("second response body = %q, want %q", body2, "chunk1chunk2")
+	}
+
+	<-done
+}
+
+// TestHandleConnection100Continue verifies a request carrying an
+// "Expect: 100-continue" header gets the interim 100 response before the
+// client sends its body, then still receives a well-formed final response.
+func TestHandleConnection100Continue(t *testing.T) {
+	router := NewRouter()
+	router.POST("/echo", func(ctx *Context) error {
+		body, errRead := io.ReadAll(ctx.Request.Body)
+		if errRead != nil {
+			return errRead
+		}
+
+		return ctx.String(string(body))
+	})
+
+	server := newPipelineTestServer(router)
+
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+
+	conn := NewConnection(serverSide)
+
+	done := make(chan struct{})
+	go func() {
+		server.handleConnection(conn)
+		close(done)
+	}()
+
+	request := "POST /echo HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"Expect: 100-continue\r\n" +
+		"Content-Length: 4\r\n" +
+		"Connection: close\r\n\r\n"
+
+	go func() {
+		_, _ = clientSide.Write([]byte(request))
+		_, _ = clientSide.Write([]byte("body"))
+	}()
+
+	reader := bufio.NewReader(clientSide)
+
+	line, errLine := reader.ReadString('\n')
+	if errLine != nil {
+		t.Fatalf("reading 100-continue status line: %v", errLine)
+	}
+
+	if !bytes.Contains([]byte(line), []byte("100 Continue")) {
+		t.Fatalf("status line = %q, want a 100 Continue", line)
+	}
+
+	// The interim response's own blank line.
+	if _, errBlank := reader.ReadString('\n'); errBlank != nil {
+		t.Fatalf("reading 100-continue blank line: %v", errBlank)
+	}
+
+	resp, errRead := http.ReadResponse(reader, nil)
+	if errRead != nil {
+		t.Fatalf("reading final response: %v", errRead)
+	}
+
+	body, errBody := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if errBody != nil {
+		t.Fatalf("reading final response body: %v", errBody)
+	}
+
+	if string(body) != "body" {
+		t.Fatalf("final response body = %q, want %q", body, "body")
+	}
+
+	<-done
+}
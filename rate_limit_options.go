@@ -0,0 +1,34 @@
+package main
+
+// WithMaxConnections caps the number of concurrently open connections;
+// connections accepted past the cap are closed immediately in Run, before
+// a handler goroutine is spawned.
+func WithMaxConnections(max int) Option {
+	return func(s *Server) {
+		s.limiter().maxConnections = int64(max)
+	}
+}
+
+// WithMaxInFlightRequests caps the number of requests being served at once
+// across all connections; requests past the cap get a 429 response.
+func WithMaxInFlightRequests(max int) Option {
+	return func(s *Server) {
+		s.limiter().maxInFlight = int64(max)
+	}
+}
+
+// WithRateLimit configures a global requests/sec token bucket (globalRPS,
+// globalBurst) and a per-IP one (perIPRPS, perIPBurst), the latter tracked
+// in an LRU cache capped at maxTrackedIPs distinct source IPs so memory
+// stays bounded under an attack spraying addresses.
+func WithRateLimit(globalRPS float64, globalBurst int, perIPRPS float64, perIPBurst, maxTrackedIPs int) Option {
+	return func(s *Server) {
+		limiter := s.limiter()
+
+		if globalRPS > 0 {
+			limiter.global = newTokenBucket(globalRPS, globalBurst)
+		}
+
+		limiter.perIP = newIPBucketCache(maxTrackedIPs, perIPRPS, perIPBurst)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Handler processes a single request through a Context.
+type Handler func(*Context) error
+
+// Middleware wraps a Handler to form a chain, e.g. logging, recovery, gzip,
+// or auth, applied in the order passed to Router.Use.
+type Middleware func(Handler) Handler
+
+type route struct {
+	method   string
+	segments []string
+	handler  Handler
+}
+
+// Router matches incoming requests to a registered Handler by method and
+// path, supporting ":name" path parameters and a trailing "*name" wildcard.
+type Router struct {
+	routes     []*route
+	middleware []Middleware
+}
+
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Use appends middleware to the chain wrapping every matched Handler, in the
+// order given: the first middleware passed runs first.
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Handle registers handler for method and pattern, e.g. Handle(http.MethodGet, "/users/:id", handler).
+func (r *Router) Handle(method, pattern string, handler Handler) {
+	r.routes = append(r.routes, &route{
+		method:   method,
+		segments: splitPath(pattern),
+		handler:  handler,
+	})
+}
+
+func (r *Router) GET(pattern string, handler Handler) {
+	r.Handle(http.MethodGet, pattern, handler)
+}
+
+func (r *Router) POST(pattern string, handler Handler) {
+	r.Handle(http.MethodPost, pattern, handler)
+}
+
+func (r *Router) PUT(pattern string, handler Handler) {
+	r.Handle(http.MethodPut, pattern, handler)
+}
+
+func (r *Router) DELETE(pattern string, handler Handler) {
+	r.Handle(http.MethodDelete, pattern, handler)
+}
+
+// dispatch resolves ctx.Request to a registered route, runs it through the
+// middleware chain, and invokes the resulting Handler. Unmatched requests
+// get a plain 404. A panic in the middleware chain or Handler is recovered
+// and reported as an error instead of crashing the connection's goroutine
+// (and, since panics are not connection-scoped, the whole process).
+func (r *Router) dispatch(ctx *Context) (errDispatch error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			errDispatch = fmt.Errorf("panic in handler: %v", rec)
+		}
+	}()
+
+	matched, params, ok := r.match(ctx.Request.Method, ctx.Request.URL.Path)
+	if !ok {
+		return ctx.Status(http.StatusNotFound).String(http.StatusText(http.StatusNotFound))
+	}
+
+	ctx.Params = params
+
+	handler := matched.handler
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+
+	return handler(ctx)
+}
+
+func (r *Router) match(method, path string) (*route, map[string]string, bool) {
+	pathSegments := splitPath(path)
+
+	for _, rt := range r.routes {
+		if rt.method != method {
+			continue
+		}
+
+		params, ok := matchSegments(rt.segments, pathSegments)
+		if ok {
+			return rt, params, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+func matchSegments(pattern, path []string) (map[string]string, bool) {
+	params := make(map[string]string)
+
+	for i, seg := range pattern {
+		if strings.HasPrefix(seg, "*") {
+			name := strings.TrimPrefix(seg, "*")
+			if name == "" {
+				name = "*"
+			}
+
+			params[name] = strings.Join(path[i:], "/")
+
+			return params, true
+		}
+
+		if i >= len(path) {
+			return nil, false
+		}
+
+		if strings.HasPrefix(seg, ":") {
+			params[seg[1:]] = path[i]
+
+			continue
+		}
+
+		if seg != path[i] {
+			return nil, false
+		}
+	}
+
+	if len(pattern) != len(path) {
+		return nil, false
+	}
+
+	return params, true
+}
+
+func splitPath(path string) []string {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return nil
+	}
+
+	return strings.Split(trimmed, "/")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestContext(method, target string) *Context {
+	req := httptest.NewRequest(method, target, nil)
+
+	return &Context{
+		Request:    req,
+		Ctx:        req.Context(),
+		statusCode: http.StatusOK,
+	}
+}
+
+func TestRouterMatchPathParams(t *testing.T) {
+	router := NewRouter()
+
+	var gotID string
+	router.GET("/users/:id", func(ctx *Context) error {
+		gotID = ctx.Param("id")
+
+		return nil
+	})
+
+	matched, params, ok := router.match(http.MethodGet, "/users/42")
+	if !ok {
+		t.Fatalf("expected a match for /users/42")
+	}
+
+	if params["id"] != "42" {
+		t.Fatalf("id param = %q, want %q", params["id"], "42")
+	}
+
+	if errHandler := matched.handler(&Context{Params: params}); errHandler != nil {
+		t.Fatalf("handler returned error: %v", errHandler)
+	}
+
+	if gotID != "42" {
+		t.Fatalf("gotID = %q, want %q", gotID, "42")
+	}
+}
+
+func TestRouterWildcardSuffix(t *testing.T) {
+	router := NewRouter()
+	router.GET("/static/*path", func(*Context) error { return nil })
+
+	_, params, ok := router.match(http.MethodGet, "/static/js/app.js")
+	if !ok {
+		t.Fatalf("expected a match under the wildcard route")
+	}
+
+	if params["path"] != "js/app.js" {
+		t.Fatalf("path param = %q, want %q", params["path"], "js/app.js")
+	}
+}
+
+func TestRouterMethodMismatchAndNotFound(t *testing.T) {
+	router := NewRouter()
+	router.GET("/users/:id", func(*Context) error { return nil })
+
+	if _, _, ok := router.match(http.MethodPost, "/users/42"); ok {
+		t.Fatalf("expected no match for a different method")
+	}
+
+	if _, _, ok := router.match(http.MethodGet, "/unknown"); ok {
+		t.Fatalf("expected no match for an unregistered path")
+	}
+}
+
+func TestRouterMiddlewareOrder(t *testing.T) {
+	router := NewRouter()
+
+	var order []string
+	mw := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx *Context) error {
+				order = append(order, name)
+
+				return next(ctx)
+			}
+		}
+	}
+
+	router.Use(mw("first"), mw("second"))
+	router.GET("/", func(*Context) error {
+		order = append(order, "handler")
+
+		return nil
+	})
+
+	ctx := newTestContext(http.MethodGet, "/")
+
+	if errDispatch := router.dispatch(ctx); errDispatch != nil {
+		t.Fatalf("dispatch returned error: %v", errDispatch)
+	}
+
+	want := []string{"first", "second", "handler"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+
+	for i, name := range want {
+		if order[i] != name {
+			t.Fatalf("order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestRouterDispatchRecoversPanic(t *testing.T) {
+	router := NewRouter()
+	router.GET("/boom", func(*Context) error {
+		panic("kaboom")
+	})
+
+	ctx := newTestContext(http.MethodGet, "/boom")
+
+	errDispatch := router.dispatch(ctx)
+	if errDispatch == nil {
+		t.Fatalf("expected dispatch to report the recovered panic as an error")
+	}
+}
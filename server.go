@@ -3,41 +3,154 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
+	"path"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/TudorHulban/https-server/internal/clienthello"
 )
 
 type Server struct {
 	bufferPool sync.Pool
 	tlsConfig  *tls.Config
+	router     *Router
+
+	certMu       sync.RWMutex
+	certificates map[string]*tls.Certificate
+	certOrder    []string // registration order of certificates' keys, for deterministic match precedence
+
+	mu       sync.Mutex
+	listener net.Listener
+
+	baseCtx    context.Context
+	cancelBase context.CancelFunc
+
+	connections sync.Map // *Connection -> struct{}
+
+	shutdownMu sync.Mutex
+	onShutdown []func()
+
+	rateLimiter *Limiter
+}
+
+// limiter lazily creates the Server's Limiter, so rate-limit Options can
+// configure it during NewServer without every Server paying for one.
+func (s *Server) limiter() *Limiter {
+	if s.rateLimiter == nil {
+		s.rateLimiter = newLimiter()
+	}
+
+	return s.rateLimiter
+}
+
+// Metrics reports accepted/rejected/in-flight counters for the Server's
+// rate limiter. A Server with no rate-limiting Options configured reports
+// the zero value.
+func (s *Server) Metrics() Metrics {
+	if s.rateLimiter == nil {
+		return Metrics{}
+	}
+
+	return s.rateLimiter.metrics()
 }
 
-func NewServer(certFile, keyFile string) (*Server, error) {
+// NewServer loads certFile/keyFile as the default certificate, then applies
+// opts (e.g. WithModernTLS, WithClientCertPinning) on top of that baseline
+// TLS configuration.
+func NewServer(certFile, keyFile string, router *Router, opts ...Option) (*Server, error) {
 	cert, errLoadX509 := tls.LoadX509KeyPair(certFile, keyFile)
 	if errLoadX509 != nil {
 		return nil,
 			fmt.Errorf("load x509 key pair: %w", errLoadX509)
 	}
 
-	return &Server{
-			bufferPool: sync.Pool{
-				New: func() interface{} {
-					return bytes.NewBuffer(nil)
-				},
-			},
+	if router == nil {
+		router = NewRouter()
+	}
 
-			tlsConfig: &tls.Config{
-				Certificates: []tls.Certificate{cert},
+	server := &Server{
+		bufferPool: sync.Pool{
+			New: func() interface{} {
+				return bytes.NewBuffer(nil)
 			},
 		},
-		nil
+
+		router: router,
+
+		certificates: map[string]*tls.Certificate{
+			"*": &cert,
+		},
+		certOrder: []string{"*"},
+	}
+
+	server.baseCtx, server.cancelBase = context.WithCancel(context.Background())
+
+	server.tlsConfig = &tls.Config{
+		GetCertificate: server.getCertificate,
+	}
+
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	return server, nil
+}
+
+// AddCertificate registers certFile/keyFile to serve hostGlob (a path.Match
+// pattern, e.g. "*.example.com"), so one Server can terminate multiple SNI
+// hostnames. hostGlob "*" sets the fallback used when no other pattern, or
+// no SNI at all, matches.
+func (s *Server) AddCertificate(hostGlob, certFile, keyFile string) error {
+	cert, errLoadX509 := tls.LoadX509KeyPair(certFile, keyFile)
+	if errLoadX509 != nil {
+		return fmt.Errorf("load x509 key pair for %q: %w", hostGlob, errLoadX509)
+	}
+
+	s.certMu.Lock()
+	defer s.certMu.Unlock()
+
+	if _, exists := s.certificates[hostGlob]; !exists {
+		s.certOrder = append(s.certOrder, hostGlob)
+	}
+
+	s.certificates[hostGlob] = &cert
+
+	return nil
+}
+
+// getCertificate matches hello.ServerName against registered host globs in
+// registration order and returns the first match, falling back to "*" if
+// none matches. Registration order (not map iteration, which Go randomizes)
+// is what makes this deterministic when more than one glob matches the same
+// SNI name.
+func (s *Server) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.certMu.RLock()
+	defer s.certMu.RUnlock()
+
+	for _, hostGlob := range s.certOrder {
+		if hostGlob == "*" {
+			continue
+		}
+
+		matched, errMatch := path.Match(hostGlob, hello.ServerName)
+		if errMatch == nil && matched {
+			return s.certificates[hostGlob], nil
+		}
+	}
+
+	if cert, ok := s.certificates["*"]; ok {
+		return cert, nil
+	}
+
+	return nil, fmt.Errorf("no certificate configured for host %q", hello.ServerName)
 }
 
 func (s *Server) Run(address string) error {
@@ -45,39 +158,116 @@ func (s *Server) Run(address string) error {
 	if errListen != nil {
 		return fmt.Errorf("listener start: %w", errListen)
 	}
-	defer listener.Close()
 
-	listenerTLS := tls.NewListener(listener, s.tlsConfig)
-	defer listenerTLS.Close()
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	defer listener.Close()
 
 	log.Printf("Listening on %s (HTTPS)...", address)
 
 	for {
-		conn, err := listenerTLS.Accept()
+		rawConn, err := listener.Accept()
 		if err != nil {
+			select {
+			case <-s.baseCtx.Done():
+				return nil // Shutdown/Close closed the listener on purpose
+			default:
+			}
+
 			log.Printf("failed to accept connection: %v", err)
 
 			continue
 		}
 
-		go s.handleConnection(
-			NewConnection(conn),
-		)
+		if s.rateLimiter != nil && !s.rateLimiter.allowConnection() {
+			// Unlike the in-flight request cap in onTraffic, this can't
+			// reply with a 429: no TLS handshake has happened yet, so there
+			// is no HTTP connection to write one on, and paying for the
+			// handshake just to say "no" defeats the point of capping
+			// concurrent connections for backpressure. Closing the raw
+			// connection is the deliberate exception to "respond with 429
+			// rather than dropping the connection".
+			_ = rawConn.Close()
+
+			continue
+		}
+
+		// tls.Server is driven directly (instead of tls.NewListener) so the
+		// ClientHello bytes can be teed off the wire before the handshake
+		// consumes them.
+		capture := newHelloCaptureConn(rawConn)
+		tlsConn := tls.Server(capture, s.tlsConfig)
+
+		c := NewConnection(tlsConn)
+		s.trackConnection(c)
+
+		go func() {
+			defer s.untrackConnection(c)
+
+			// Defense in depth: dispatch already recovers panics from
+			// Handlers/Middleware, but an unrecovered panic anywhere in this
+			// goroutine would otherwise crash the whole process, taking
+			// every other live connection down with it.
+			defer func() {
+				if rec := recover(); rec != nil {
+					log.Printf("panic in connection handler: %v", rec)
+				}
+			}()
+
+			if s.rateLimiter != nil {
+				defer s.rateLimiter.releaseConnection()
+			}
+
+			if errHandshake := tlsConn.Handshake(); errHandshake != nil {
+				log.Printf("tls handshake failed: %v", errHandshake)
+
+				_ = tlsConn.Close()
+
+				return
+			}
+
+			if info, errParse := clienthello.Parse(capture.stopCapture()); errParse == nil {
+				c.setClientHello(info)
+			}
+
+			s.handleConnection(c)
+		}()
 	}
 }
 
 func (s *Server) handleConnection(conn *Connection) {
 	defer conn.Close()
 
-	// Set a read deadline for idle connections
-	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	// A single buffered reader persists across every pipelined request on
+	// this connection, so http.ReadRequest never loses bytes left over from
+	// a previous parse (e.g. a second request queued in the same TCP read).
+	reader := bufio.NewReader(conn.RawConn())
 
 	for {
-		if errOnTraffic := s.onTraffic(conn); errOnTraffic != nil {
+		select {
+		case <-s.baseCtx.Done():
+			return
+		default:
+		}
+
+		// Set a read deadline for idle connections
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		keepAlive, errOnTraffic := s.onTraffic(conn, reader)
+		if errOnTraffic != nil {
+			if errOnTraffic != io.EOF {
+				log.Printf("connection error: %v", errOnTraffic)
+			}
 
 			break
 		}
 
+		if !keepAlive {
+			break
+		}
+
 		// Reset the timeout after successful activity
 		conn.SetReadDeadline(time.Now().Add(3 * time.Second))
 	}
@@ -93,7 +283,27 @@ func (s *Server) SendStatus(statusCode int) []byte {
 	buffer.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123))) // Add Date header
 	buffer.WriteString("\r\n")
 
-	return buffer.Bytes()
+	// Copy out before the deferred Put: the pool can hand this *bytes.Buffer
+	// to another goroutine (and Reset it) before the caller gets a chance to
+	// write buffer.Bytes() to the wire.
+	return append([]byte(nil), buffer.Bytes()...)
+}
+
+// SendTooManyRequests builds a 429 response advising the client to retry
+// after retryAfterSeconds, used when a rate or in-flight limit is hit.
+func (s *Server) SendTooManyRequests(retryAfterSeconds int) []byte {
+	buffer := s.bufferPool.Get().(*bytes.Buffer)
+	defer s.bufferPool.Put(buffer)
+	buffer.Reset()
+
+	buffer.WriteString(fmt.Sprintf("HTTP/1.1 %d %s\r\n", http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests)))
+	buffer.WriteString(fmt.Sprintf("Retry-After: %d\r\n", retryAfterSeconds))
+	buffer.WriteString("Content-Length: 0\r\n")
+	buffer.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123)))
+	buffer.WriteString("\r\n")
+
+	// Copy out before the deferred Put: see SendStatus.
+	return append([]byte(nil), buffer.Bytes()...)
 }
 
 func (s *Server) SendBody(statusCode int, body string) []byte {
@@ -107,35 +317,96 @@ func (s *Server) SendBody(statusCode int, body string) []byte {
 	buffer.WriteString("\r\n")
 	buffer.WriteString(body)
 
-	return buffer.Bytes()
+	// Copy out before the deferred Put: see SendStatus.
+	return append([]byte(nil), buffer.Bytes()...)
 }
 
-func (s *Server) onTraffic(conn *Connection) error {
-	data, errRead := conn.Read()
-	if errRead != nil {
-		if errRead == io.EOF {
-			return nil // client closed the connection gracefully
+// onTraffic parses and serves a single request off reader, reporting
+// whether the connection should stay open for another pipelined request.
+func (s *Server) onTraffic(conn *Connection, reader *bufio.Reader) (bool, error) {
+	req, errParse := http.ReadRequest(reader)
+	if errParse != nil {
+		if errParse == io.EOF {
+			return false, io.EOF // client closed the connection gracefully
 		}
 
-		return errRead // actual read error
+		_ = conn.Write([]byte("HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\n\r\n"))
+
+		return false, nil // Don't keep a connection open after a bad request
 	}
 
-	bufReader := bufio.NewReader(bytes.NewReader(data))
+	if req.Header.Get("Expect") == "100-continue" {
+		if errContinue := conn.Write([]byte("HTTP/1.1 100 Continue\r\n\r\n")); errContinue != nil {
+			return false, errContinue
+		}
+	}
 
-	req, errParse := http.ReadRequest(bufReader)
-	if errParse != nil {
-		go log.Printf("failed to parse HTTP request: %v", errParse)
+	if s.rateLimiter != nil {
+		if !s.rateLimiter.allowRequest(clientIP(conn)) {
+			_ = conn.Write(s.SendTooManyRequests(1))
 
-		_ = conn.Write([]byte("HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\n\r\n"))
+			_, _ = io.Copy(io.Discard, req.Body)
+			_ = req.Body.Close()
+
+			return keepAlive(req), nil
+		}
 
-		return nil // Don't close the connection on a bad request
+		defer s.rateLimiter.releaseRequest()
 	}
 
-	_ = conn.Write(s.SendStatus(http.StatusOK))
+	ctx := newContext(s, conn, req, nil)
+
+	if errDispatch := s.router.dispatch(ctx); errDispatch != nil {
+		log.Printf("handler error: %v", errDispatch)
+
+		// Only fall back to a synthesized 500 if the Handler never started
+		// writing its own response; otherwise this would write a second,
+		// conflicting response onto the same connection.
+		if !ctx.Written() {
+			_ = conn.Write(s.SendStatus(http.StatusInternalServerError))
+		}
+	} else if !ctx.Written() {
+		// A Handler that returns nil without writing anything still has to
+		// produce a response: nothing else marks where this request's
+		// response ends and the next pipelined one begins, so writing
+		// nothing would desync the connection. Mirror net/http's
+		// implicit-write-on-return behavior with an empty 200.
+		_ = conn.Write(s.SendStatus(http.StatusOK))
+	}
+
+	// Drain any unread body so the next pipelined request starts at the
+	// right offset in the shared reader.
+	_, _ = io.Copy(io.Discard, req.Body)
+	_ = req.Body.Close()
+
+	return keepAlive(req), nil
+}
+
+// clientIP returns conn's remote address with any port stripped, for use as
+// a per-IP rate-limit key.
+func clientIP(conn *Connection) string {
+	addr := conn.RawConn().RemoteAddr()
+	if addr == nil {
+		return ""
+	}
+
+	host, _, errSplit := net.SplitHostPort(addr.String())
+	if errSplit != nil {
+		return addr.String()
+	}
+
+	return host
+}
+
+// keepAlive reports whether the connection should stay open after req,
+// per HTTP/1.1 (keep-alive unless "Connection: close") and HTTP/1.0
+// (closed unless "Connection: keep-alive") defaults.
+func keepAlive(req *http.Request) bool {
+	connHeader := strings.ToLower(req.Header.Get("Connection"))
 
-	if strings.ToLower(req.Header.Get("Connection")) == "close" {
-		return io.EOF // Signal to close the connection
+	if req.ProtoAtLeast(1, 1) {
+		return connHeader != "close"
 	}
 
-	return nil // Keep the connection open
+	return connHeader == "keep-alive"
 }
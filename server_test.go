@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/tls"
+	"testing"
+)
+
+func TestGetCertificateSNIGlobMatch(t *testing.T) {
+	wildcard := &tls.Certificate{}
+	api := &tls.Certificate{}
+	fallback := &tls.Certificate{}
+
+	server := &Server{
+		certificates: map[string]*tls.Certificate{
+			"*.example.com": wildcard,
+			"api.internal":  api,
+			"*":             fallback,
+		},
+		certOrder: []string{"*.example.com", "api.internal", "*"},
+	}
+
+	tests := []struct {
+		name       string
+		serverName string
+		want       *tls.Certificate
+	}{
+		{"wildcard match", "www.example.com", wildcard},
+		{"exact match", "api.internal", api},
+		{"no match falls back", "unknown.host", fallback},
+		{"empty SNI falls back", "", fallback},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errGet := server.getCertificate(&tls.ClientHelloInfo{ServerName: tt.serverName})
+			if errGet != nil {
+				t.Fatalf("getCertificate(%q): %v", tt.serverName, errGet)
+			}
+
+			if got != tt.want {
+				t.Fatalf("getCertificate(%q) = %p, want %p", tt.serverName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCertificateAmbiguousMatchUsesRegistrationOrder(t *testing.T) {
+	first := &tls.Certificate{}
+	second := &tls.Certificate{}
+
+	server := &Server{
+		certificates: map[string]*tls.Certificate{
+			"*.example.com": first,
+			"api.*.com":     second,
+		},
+		certOrder: []string{"*.example.com", "api.*.com"},
+	}
+
+	// Both globs match "api.example.com"; registration order must decide the
+	// winner deterministically, not map iteration order.
+	for i := 0; i < 10; i++ {
+		got, errGet := server.getCertificate(&tls.ClientHelloInfo{ServerName: "api.example.com"})
+		if errGet != nil {
+			t.Fatalf("getCertificate: %v", errGet)
+		}
+
+		if got != first {
+			t.Fatalf("getCertificate() = %p, want the first-registered match %p", got, first)
+		}
+	}
+}
+
+func TestGetCertificateNoMatchNoFallback(t *testing.T) {
+	server := &Server{
+		certificates: map[string]*tls.Certificate{
+			"api.internal": {},
+		},
+	}
+
+	if _, errGet := server.getCertificate(&tls.ClientHelloInfo{ServerName: "unknown.host"}); errGet == nil {
+		t.Fatalf("expected an error when no pattern matches and there is no fallback")
+	}
+}
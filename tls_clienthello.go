@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"net"
+
+	"github.com/TudorHulban/https-server/internal/clienthello"
+)
+
+// helloCaptureConn tees every byte read off the wire into buf until
+// stopCapture is called, so the initial ClientHello record can be parsed
+// after crypto/tls has consumed it during the handshake.
+type helloCaptureConn struct {
+	net.Conn
+
+	buf       bytes.Buffer
+	capturing bool
+}
+
+func newHelloCaptureConn(conn net.Conn) *helloCaptureConn {
+	return &helloCaptureConn{
+		Conn:      conn,
+		capturing: true,
+	}
+}
+
+func (h *helloCaptureConn) Read(p []byte) (int, error) {
+	n, err := h.Conn.Read(p)
+	if h.capturing && n > 0 {
+		h.buf.Write(p[:n])
+	}
+
+	return n, err
+}
+
+// stopCapture freezes and returns the bytes captured so far.
+func (h *helloCaptureConn) stopCapture() []byte {
+	h.capturing = false
+
+	return h.buf.Bytes()
+}
+
+// ClientHello returns the parsed ClientHello the client opened this
+// connection with, or nil if it could not be captured or parsed.
+func (c *Connection) ClientHello() *clienthello.Info {
+	return c.clientHello
+}
+
+func (c *Connection) setClientHello(info *clienthello.Info) {
+	c.clientHello = info
+}
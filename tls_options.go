@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Option configures a Server's TLS behavior at construction time, applied
+// in order after the default certificate has been loaded.
+type Option func(*Server)
+
+// WithModernTLS restricts negotiation to TLS 1.3, whose suite selection
+// (AEAD only) is fixed by crypto/tls.
+func WithModernTLS() Option {
+	return func(s *Server) {
+		s.tlsConfig.MinVersion = tls.VersionTLS13
+		s.tlsConfig.MaxVersion = tls.VersionTLS13
+	}
+}
+
+// WithRestrictedTLS allows TLS 1.2 and 1.3 but narrows the TLS 1.2 suite
+// list to a curated set of AEAD ciphers, dropping CBC and RC4 suites.
+func WithRestrictedTLS() Option {
+	return func(s *Server) {
+		s.tlsConfig.MinVersion = tls.VersionTLS12
+		s.tlsConfig.MaxVersion = tls.VersionTLS13
+		s.tlsConfig.CipherSuites = []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		}
+	}
+}
+
+// WithPinnedTLS12 pins negotiation to TLS 1.2 only, for clients that cannot
+// yet speak TLS 1.3.
+func WithPinnedTLS12() Option {
+	return func(s *Server) {
+		s.tlsConfig.MinVersion = tls.VersionTLS12
+		s.tlsConfig.MaxVersion = tls.VersionTLS12
+	}
+}
+
+// WithClientCertPinning requires a client certificate and rejects any
+// handshake whose leaf SPKI SHA-256 fingerprint (lowercase hex) is not in
+// sha256Hex.
+func WithClientCertPinning(sha256Hex ...string) Option {
+	pins := make(map[string]struct{}, len(sha256Hex))
+	for _, fingerprint := range sha256Hex {
+		pins[strings.ToLower(fingerprint)] = struct{}{}
+	}
+
+	return func(s *Server) {
+		s.tlsConfig.ClientAuth = tls.RequireAnyClientCert
+		s.tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("client cert pinning: no client certificate presented")
+			}
+
+			leaf, errParse := x509.ParseCertificate(rawCerts[0])
+			if errParse != nil {
+				return fmt.Errorf("client cert pinning: parse leaf certificate: %w", errParse)
+			}
+
+			sum := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+			fingerprint := hex.EncodeToString(sum[:])
+
+			if _, ok := pins[fingerprint]; !ok {
+				return fmt.Errorf("client cert pinning: certificate %s is not pinned", fingerprint)
+			}
+
+			return nil
+		}
+	}
+}